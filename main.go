@@ -8,6 +8,7 @@ import (
 	"os"
 	"strings"
 	"syscall"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"golang.org/x/term"
@@ -17,24 +18,120 @@ import (
 func main() {
 	// --- Command-line flags ---
 	var (
-		flagUsername string
-		flagPassword string
+		flagServer    string
+		flagUsername  string
+		flagPassword  string
+		flagProfile   string
+		flagAddServer string
 	)
+	flag.StringVar(&flagServer, "server", "http://localhost:8081", "Server base URL (used with --username/--add-server)")
 	flag.StringVar(&flagUsername, "username", "", "Username to log in with")
 	flag.StringVar(&flagPassword, "password", "", "Password to log in with")
+	flag.StringVar(&flagProfile, "profile", "", "Saved server profile to connect with")
+	flag.StringVar(&flagAddServer, "add-server", "", "Name under which to save a new server profile")
 	flag.Parse()
 
-//	serverBaseURL := "https://chat.sarahsforge.dev:443"
-	serverBaseURL := "http://localhost:8081"
-	fmt.Printf("Attempting to connect to server at %s\n", serverBaseURL)
+	store, err := tui.NewProfileStore()
+	if err != nil {
+		log.Fatalf("Failed to load server profiles: %v", err)
+	}
 
-	client := tui.NewApiClient(serverBaseURL)
 	reader := bufio.NewReader(os.Stdin)
 
-	username := strings.TrimSpace(flagUsername)
-	password := flagPassword
+	var (
+		client      *tui.ApiClient
+		profileName string
+	)
+
+	switch {
+	case flagAddServer != "":
+		client, err = addServerInteractive(store, reader, flagAddServer, flagServer, flagUsername, flagPassword)
+		if err != nil {
+			log.Fatalf("Failed to add server: %v", err)
+		}
+		profileName = flagAddServer
+
+	case flagProfile != "":
+		client, err = connectWithProfile(store, flagProfile)
+		if err != nil {
+			log.Fatalf("Failed to connect with profile %q: %v", flagProfile, err)
+		}
+		profileName = flagProfile
+
+	case flagUsername != "" || flagPassword != "" || len(store.Profiles()) == 0:
+		client = tui.NewApiClient(flagServer)
+		if err := promptAndLogin(client, reader, flagUsername, flagPassword); err != nil {
+			log.Fatalf("Login failed: %v", err)
+		}
+		fmt.Println("Login successful! Starting chat...")
+	}
+
+	// --- Launch UI ---
+	// With no client (no flags given and saved profiles exist), InitialModel
+	// starts at the server picker and the user chooses a profile there.
+	p := tea.NewProgram(tui.InitialModel(store, profileName, client), tea.WithAltScreen(), tea.WithMouseAllMotion())
+	// Send blocks until p.Run starts reading messages, so it can't run inline here.
+	go p.Send(tui.ProgramReadyMsg{Program: p})
 
-	// --- Login Loop ---
+	if client != nil {
+		go client.ConnectAndListen(p)
+	}
+
+	if _, err := p.Run(); err != nil {
+		log.Fatalf("UI Error: %v", err)
+	}
+	fmt.Println("Goodbye!")
+}
+
+// connectWithProfile loads a saved profile's stored credentials and installs
+// them on a fresh ApiClient, without re-prompting for a password.
+func connectWithProfile(store *tui.ProfileStore, name string) (*tui.ApiClient, error) {
+	profile, ok := store.Find(name)
+	if !ok {
+		return nil, fmt.Errorf("no such profile: %s", name)
+	}
+	token, err := store.Token(name)
+	if err != nil {
+		return nil, err
+	}
+	client := tui.NewApiClient(profile.BaseURL)
+	client.SetSession(profile.Username, token)
+	_ = store.Touch(name, time.Now())
+	return client, nil
+}
+
+// addServerInteractive prompts for a username or password not already
+// given on the command line, logs in, and saves the result as a new profile
+// under name. The server URL is prompted for only when server is empty
+// (--server=""), since --server otherwise supplies its default.
+func addServerInteractive(store *tui.ProfileStore, reader *bufio.Reader, name, server, username, password string) (*tui.ApiClient, error) {
+	if server == "" {
+		fmt.Print("Enter server URL: ")
+		raw, _ := reader.ReadString('\n')
+		server = strings.TrimSpace(raw)
+	}
+	client := tui.NewApiClient(server)
+	if err := promptAndLogin(client, reader, username, password); err != nil {
+		return nil, err
+	}
+	profile := tui.Profile{
+		Name:     name,
+		BaseURL:  server,
+		Username: client.User.Username,
+		Token:    client.Token(),
+		LastUsed: time.Now(),
+	}
+	if err := store.Upsert(profile); err != nil {
+		return nil, fmt.Errorf("saving profile: %w", err)
+	}
+	fmt.Printf("Saved server profile %q.\n", name)
+	return client, nil
+}
+
+// promptAndLogin runs the interactive username/password loop against
+// client, prompting for whichever of username/password wasn't already
+// supplied, and retrying on failure.
+func promptAndLogin(client *tui.ApiClient, reader *bufio.Reader, username, password string) error {
 	for {
 		if username == "" {
 			fmt.Print("Enter username: ")
@@ -46,7 +143,7 @@ func main() {
 			fmt.Print("Enter password: ")
 			bytePassword, err := term.ReadPassword(int(syscall.Stdin))
 			if err != nil {
-				log.Fatalf("Failed to read password: %v", err)
+				return fmt.Errorf("failed to read password: %w", err)
 			}
 			password = string(bytePassword)
 			fmt.Println()
@@ -55,22 +152,9 @@ func main() {
 		err := client.Login(username, password)
 		if err != nil {
 			fmt.Printf("Login failed: %v. Please try again.\n", err)
-			// Reset password so it is prompted again if login fails.
 			password = ""
 			continue
 		}
-		fmt.Println("Login successful! Starting chat...")
-		break
+		return nil
 	}
-
-	// --- Launch UI ---
-	p := tea.NewProgram(tui.InitialModel(client), tea.WithAltScreen(), tea.WithMouseAllMotion())
-
-	// Start the WebSocket listener.
-	go client.ConnectAndListen(p)
-
-	if _, err := p.Run(); err != nil {
-		log.Fatalf("UI Error: %v", err)
-	}
-	fmt.Println("Goodbye!")
-}
\ No newline at end of file
+}
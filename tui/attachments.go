@@ -0,0 +1,201 @@
+package tui
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// attachmentFetchTimeout bounds how long a single inline-image fetch may
+// block before it's treated as a failure, so a hanging server can never
+// stall the fetch beyond this.
+const attachmentFetchTimeout = 5 * time.Second
+
+var attachmentHTTPClient = &http.Client{Timeout: attachmentFetchTimeout}
+
+// attachmentCacheEntry holds the outcome of a previous fetchAttachmentBytes
+// call, keyed by attachment ID, so the same image is never fetched twice.
+type attachmentCacheEntry struct {
+	data []byte
+	err  error
+}
+
+// attachmentLoadedMsg reports the result of a background fetch started by
+// fetchAttachmentCmd.
+type attachmentLoadedMsg struct {
+	id   int64
+	data []byte
+	err  error
+}
+
+// fetchAttachmentCmd fetches att's bytes off the UI goroutine, as a tea.Cmd,
+// so a slow or hanging media server can't block the Bubble Tea event loop.
+func fetchAttachmentCmd(att Attachment) tea.Cmd {
+	return func() tea.Msg {
+		data, err := fetchAttachmentBytes(att.URL)
+		return attachmentLoadedMsg{id: att.ID, data: data, err: err}
+	}
+}
+
+// terminalGraphicsProtocol identifies which inline-image escape sequence
+// protocol, if any, the current terminal supports.
+type terminalGraphicsProtocol int
+
+const (
+	protocolNone terminalGraphicsProtocol = iota
+	protocolKitty
+	protocolITerm2
+	protocolSixel
+)
+
+// detectGraphicsProtocol feature-detects inline image support from the
+// environment variables common terminal emulators set.
+func detectGraphicsProtocol() terminalGraphicsProtocol {
+	term := os.Getenv("TERM")
+	termProgram := os.Getenv("TERM_PROGRAM")
+
+	switch {
+	case termProgram == "iTerm.app":
+		return protocolITerm2
+	case strings.Contains(term, "kitty"), os.Getenv("KITTY_WINDOW_ID") != "":
+		return protocolKitty
+	case strings.Contains(term, "xterm-sixel"), strings.Contains(term, "mlterm"):
+		return protocolSixel
+	default:
+		return protocolNone
+	}
+}
+
+// renderInlineImage returns the escape sequence used to display att in the
+// viewport using bytes already fetched into cache, falling back to a styled
+// filename/size card when the terminal can't render images, att isn't one,
+// or the bytes haven't been fetched (yet, or at all).
+func renderInlineImage(att Attachment, cache map[int64]attachmentCacheEntry) string {
+	proto := detectGraphicsProtocol()
+	if proto == protocolNone || !strings.HasPrefix(att.MimeType, "image/") {
+		return renderAttachmentFallback(att)
+	}
+
+	entry, ok := cache[att.ID]
+	if !ok || entry.err != nil {
+		return renderAttachmentFallback(att)
+	}
+	data := entry.data
+
+	switch proto {
+	case protocolKitty:
+		return kittyEscape(data)
+	case protocolITerm2:
+		return iterm2Escape(data, att)
+	default:
+		// Sixel encoding needs a palette-quantizing image codec this repo
+		// doesn't depend on yet; fall back to the text card for now.
+		return renderAttachmentFallback(att)
+	}
+}
+
+func fetchAttachmentBytes(url string) ([]byte, error) {
+	resp, err := attachmentHTTPClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// kittyEscape wraps image bytes in the Kitty terminal graphics protocol.
+func kittyEscape(data []byte) string {
+	return fmt.Sprintf("\x1b_Ga=T,f=100;%s\x1b\\", base64.StdEncoding.EncodeToString(data))
+}
+
+// iterm2Escape wraps image bytes in the iTerm2 inline image protocol.
+func iterm2Escape(data []byte, att Attachment) string {
+	name := base64.StdEncoding.EncodeToString([]byte(filenameFromURL(att.URL)))
+	return fmt.Sprintf("\x1b]1337;File=name=%s;size=%d;inline=1:%s\a", name, att.Size, base64.StdEncoding.EncodeToString(data))
+}
+
+// renderAttachmentFallback renders a styled card with an icon, filename,
+// size, and a clickable OSC 8 hyperlink for non-image mime types or
+// terminals without inline-image support.
+func renderAttachmentFallback(att Attachment) string {
+	icon := "\U0001F4CE" // paperclip
+	if strings.HasPrefix(att.MimeType, "image/") {
+		icon = "\U0001F5BC" // framed picture
+	}
+	label := fmt.Sprintf("%s %s (%s)", icon, filenameFromURL(att.URL), humanSize(att.Size))
+	link := fmt.Sprintf("\x1b]8;;%s\x1b\\%s\x1b]8;;\x1b\\", att.URL, label)
+	return attachmentCardStyle.Render(link)
+}
+
+func filenameFromURL(url string) string {
+	if idx := strings.LastIndex(url, "/"); idx != -1 {
+		return url[idx+1:]
+	}
+	return url
+}
+
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// stagedFilePath recognizes a pasted or drag-and-dropped file path typed
+// into the input (many terminals insert the absolute path on drop) so it
+// can be staged as an upload without the explicit /upload command.
+func stagedFilePath(content string) (string, bool) {
+	if !strings.HasPrefix(content, "/") && !strings.HasPrefix(content, "~") {
+		return "", false
+	}
+	path := content
+	if strings.HasPrefix(path, "~") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", false
+		}
+		path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return "", false
+	}
+	return path, true
+}
+
+func runUpload(m *model, args []string) tea.Cmd {
+	if len(args) != 1 {
+		m.pushSystemMessage("usage: /upload <path>")
+		return nil
+	}
+	if len(m.channels) == 0 {
+		return nil
+	}
+	path := args[0]
+	ch := m.channels[m.activeTabIndex]
+	client := m.client
+	m.pushSystemMessage(fmt.Sprintf("uploading %s...", filepath.Base(path)))
+	return func() tea.Msg {
+		att, err := client.UploadAttachment(ch.ID, path)
+		if err != nil {
+			return commandErrorMsg{fmt.Errorf("upload failed: %w", err)}
+		}
+		if err := client.SendMessage(ch.ID, "", "", []int64{att.ID}); err != nil {
+			return commandErrorMsg{fmt.Errorf("send failed: %w", err)}
+		}
+		return nil
+	}
+}
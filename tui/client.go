@@ -2,11 +2,17 @@ package tui
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/rand"
+	"mime/multipart"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -27,23 +33,45 @@ type Channel struct {
 	Name       string `json:"name"`
 	CategoryID int64  `json:"category_id"`
 	Position   int    `json:"position"`
+	// Encrypted is copied down from the owning category when channels are
+	// flattened (see initialDataLoadedMsg); it marks a DM channel as using
+	// end-to-end encryption.
+	Encrypted bool `json:"-"`
+	// PeerID is the user ID this DM channel is with; only set when Encrypted.
+	PeerID int64 `json:"peer_id,omitempty"`
 }
 
 type ChannelCategory struct {
-	ID       int64     `json:"id"`
-	Name     string    `json:"name"`
-	Position int       `json:"position"`
-	Channels []Channel `json:"channels,omitempty"`
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Position  int       `json:"position"`
+	Channels  []Channel `json:"channels,omitempty"`
+	Encrypted bool      `json:"encrypted,omitempty"`
 }
 
 type Message struct {
-	ID        int64     `json:"id"`
-	ChannelID int64     `json:"channel_id"`
-	UserID    int64     `json:"user_id"`
-	Username  string    `json:"username"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
-	AvatarURL string    `json:"avatar_url,omitempty"`
+	ID        int64  `json:"id"`
+	ChannelID int64  `json:"channel_id"`
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	Content   string `json:"content"`
+	// Type distinguishes plain messages from client-rendered ones such as
+	// "/me" actions or local system notices. Empty is equivalent to "text".
+	Type        string       `json:"type,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	AvatarURL   string       `json:"avatar_url,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Attachment describes a file uploaded via UploadAttachment and attached to
+// a Message. Width/Height are populated by the server for image mime types.
+type Attachment struct {
+	ID       int64  `json:"id"`
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+	Size     int64  `json:"size"`
 }
 
 type WebSocketMessage struct {
@@ -51,21 +79,64 @@ type WebSocketMessage struct {
 	Payload json.RawMessage `json:"payload"`
 }
 
+// WSState reflects the health of the websocket connection, sent to the TUI
+// via wsStateMsg so the header/status line can show it.
+type WSState int
+
+const (
+	WSDisconnected WSState = iota
+	WSConnecting
+	WSConnected
+)
+
+// wsStateMsg carries its originating client alongside the new state, so a
+// background server's reconnect cycle doesn't stomp on the active one's
+// status line (see wsMsg for the same concern on message events).
+type wsStateMsg struct {
+	client *ApiClient
+	state  WSState
+}
+
 // --- API Client ---
 
 type ApiClient struct {
 	baseURL    string
 	httpClient *http.Client
-	User       *User
+	wsConn     *websocket.Conn
+
+	// mu guards User, token, and lastMessageID: they're written from
+	// whichever goroutine owns the active operation (a command goroutine
+	// for Login/ChangeNick, the websocket supervisor for lastMessageID) and
+	// read from both that goroutine and the Bubble Tea main loop.
+	mu sync.RWMutex
+	// User and token are set by Login/SetSession and cleared by Logout.
+	User *User
 	// FIX: Added a field to store the authentication token.
-	token  string
-	wsConn *websocket.Conn
+	token string
+	// lastMessageID tracks the newest message ID seen per channel, used to
+	// backfill messages missed while the websocket was down.
+	lastMessageID map[int64]int64
+
+	// reconnect lets the TUI force an immediate reconnect cycle (/reconnect)
+	// instead of waiting out the current backoff.
+	reconnect chan struct{}
 }
 
 func NewApiClient(baseURL string) *ApiClient {
 	return &ApiClient{
-		baseURL:    baseURL,
-		httpClient: &http.Client{Timeout: 10 * time.Second},
+		baseURL:       baseURL,
+		httpClient:    &http.Client{Timeout: 10 * time.Second},
+		lastMessageID: make(map[int64]int64),
+		reconnect:     make(chan struct{}, 1),
+	}
+}
+
+// ForceReconnect asks the connection supervisor to cycle the websocket
+// immediately, bypassing the current backoff wait.
+func (c *ApiClient) ForceReconnect() {
+	select {
+	case c.reconnect <- struct{}{}:
+	default:
 	}
 }
 
@@ -101,13 +172,69 @@ func (c *ApiClient) Login(username, password string) error {
 		return fmt.Errorf("failed to decode login response: %w", err)
 	}
 
+	if loginResponse.Token == "" {
+		return fmt.Errorf("login successful, but no token was received from server")
+	}
+
+	c.mu.Lock()
 	c.User = &loginResponse.User
 	c.token = loginResponse.Token
+	c.mu.Unlock()
 
-	if c.token == "" {
-		return fmt.Errorf("login successful, but no token was received from server")
-	}
+	return nil
+}
 
+// SetSession installs a previously-obtained session directly, used when
+// reconnecting via a saved profile's stored token instead of calling Login.
+func (c *ApiClient) SetSession(username, token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.User = &User{Username: username}
+	c.token = token
+}
+
+// CurrentUser returns the logged-in user, or nil if not logged in. The
+// returned *User is never mutated in place (ChangeNick swaps in a new one),
+// so it's safe to read without holding the lock.
+func (c *ApiClient) CurrentUser() *User {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.User
+}
+
+// currentToken returns the current session token under lock, for request
+// helpers that can't take c.mu themselves.
+func (c *ApiClient) currentToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+// Token returns the current session token, e.g. for persisting to a
+// profile after an interactive login.
+func (c *ApiClient) Token() string {
+	return c.currentToken()
+}
+
+// Logout invalidates the current session token on the server.
+func (c *ApiClient) Logout() error {
+	req, err := c.newAuthenticatedRequest("POST", c.baseURL+"/api/logout", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("logout failed: %s - %s", resp.Status, string(bodyBytes))
+	}
+	c.mu.Lock()
+	c.token = ""
+	c.User = nil
+	c.mu.Unlock()
 	return nil
 }
 
@@ -118,7 +245,7 @@ func (c *ApiClient) newAuthenticatedRequest(method, urlStr string, body io.Reade
 		return nil, err
 	}
 	// Add the authentication header.
-	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Authorization", "Bearer "+c.currentToken())
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
@@ -143,8 +270,14 @@ func (c *ApiClient) GetCategories() ([]ChannelCategory, error) {
 	return categories, nil
 }
 
-func (c *ApiClient) GetMessages(channelID int64) ([]Message, error) {
+// GetMessages fetches message history for a channel. If sinceID is nonzero,
+// only messages newer than that ID are returned, used to backfill history
+// missed while the websocket was disconnected.
+func (c *ApiClient) GetMessages(channelID int64, sinceID int64) ([]Message, error) {
 	url := fmt.Sprintf("%s/api/channels/%d/messages", c.baseURL, channelID)
+	if sinceID > 0 {
+		url = fmt.Sprintf("%s?since=%d", url, sinceID)
+	}
 	// FIX: Use the authenticated request helper.
 	req, err := c.newAuthenticatedRequest("GET", url, nil)
 	if err != nil {
@@ -163,17 +296,35 @@ func (c *ApiClient) GetMessages(channelID int64) ([]Message, error) {
 	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
 		messages[i], messages[j] = messages[j], messages[i]
 	}
+	if len(messages) > 0 {
+		last := messages[len(messages)-1].ID
+		c.mu.Lock()
+		if last > c.lastMessageID[channelID] {
+			c.lastMessageID[channelID] = last
+		}
+		c.mu.Unlock()
+	}
 	return messages, nil
 }
 
 // SendMessage now infers the user from the token on the server side.
 // CHANGED: Removed userID from parameters.
-func (c *ApiClient) SendMessage(channelID int64, content string) error {
+// CHANGED: Added msgType so callers (e.g. the "/me" command) can send
+// non-plain message types; pass "" for an ordinary text message.
+// CHANGED: Added attachmentIDs so a message can reference files uploaded
+// via UploadAttachment; pass nil for a message with no attachments.
+func (c *ApiClient) SendMessage(channelID int64, content string, msgType string, attachmentIDs []int64) error {
 	msgReq := map[string]interface{}{
 		"channel_id": channelID,
 		"content":    content,
 		// user_id is no longer needed; server gets it from the token.
 	}
+	if msgType != "" {
+		msgReq["type"] = msgType
+	}
+	if len(attachmentIDs) > 0 {
+		msgReq["attachment_ids"] = attachmentIDs
+	}
 	body, err := json.Marshal(msgReq)
 	if err != nil {
 		return err
@@ -197,16 +348,221 @@ func (c *ApiClient) SendMessage(channelID int64, content string) error {
 	return nil
 }
 
+// ChangeNick requests a display-name change for the logged-in user and, on
+// success, updates the cached User so the rest of the TUI sees it immediately.
+func (c *ApiClient) ChangeNick(newNick string) error {
+	body, err := json.Marshal(map[string]string{"username": newNick})
+	if err != nil {
+		return err
+	}
+	req, err := c.newAuthenticatedRequest("POST", c.baseURL+"/api/users/me/nick", bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("nick change failed: %s - %s", resp.Status, string(bodyBytes))
+	}
+	c.mu.Lock()
+	if c.User != nil {
+		updated := *c.User
+		updated.Username = newNick
+		c.User = &updated
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// GetUsers fetches the full user roster (used by the "/who" command), as
+// opposed to the lightweight presence list delivered over the websocket.
+func (c *ApiClient) GetUsers() ([]User, error) {
+	req, err := c.newAuthenticatedRequest("GET", c.baseURL+"/api/users", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var users []User
+	if err := json.NewDecoder(resp.Body).Decode(&users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+const (
+	wsInitialBackoff = 500 * time.Millisecond
+	wsMaxBackoff     = 30 * time.Second
+	wsStableUptime   = 30 * time.Second
+)
+
+// UploadAttachment multipart-POSTs the file at path to the channel's
+// attachments endpoint and returns the server-assigned Attachment.
+func (c *ApiClient) UploadAttachment(channelID int64, path string) (*Attachment, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	part, err := writer.CreateFormFile("file", filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/api/channels/%d/attachments", c.baseURL, channelID)
+	req, err := http.NewRequest("POST", url, &buf)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.currentToken())
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("upload failed: %s - %s", resp.Status, string(bodyBytes))
+	}
+
+	var att Attachment
+	if err := json.NewDecoder(resp.Body).Decode(&att); err != nil {
+		return nil, fmt.Errorf("failed to decode attachment response: %w", err)
+	}
+	return &att, nil
+}
+
+// PublishPublicKey publishes this client's long-term X25519 identity public
+// key so other users can encrypt DMs to it.
+func (c *ApiClient) PublishPublicKey(pub []byte) error {
+	body, err := json.Marshal(map[string]string{"public_key": base64.StdEncoding.EncodeToString(pub)})
+	if err != nil {
+		return err
+	}
+	req, err := c.newAuthenticatedRequest("POST", c.baseURL+"/api/users/me/pubkey", bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("publishing public key failed: %s - %s", resp.Status, string(bodyBytes))
+	}
+	return nil
+}
+
+// GetPublicKey fetches the X25519 identity public key a user has published.
+func (c *ApiClient) GetPublicKey(userID int64) ([]byte, error) {
+	url := fmt.Sprintf("%s/api/users/%d/pubkey", c.baseURL, userID)
+	req, err := c.newAuthenticatedRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("fetching public key failed: %s - %s", resp.Status, string(bodyBytes))
+	}
+	var out struct {
+		PublicKey string `json:"public_key"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(out.PublicKey)
+}
+
+// ConnectAndListen is a supervisor loop: it dials the websocket and runs
+// runConnection until the connection drops, then reconnects with jittered
+// exponential backoff (starting at wsInitialBackoff, capped at
+// wsMaxBackoff, and reset once a connection survives wsStableUptime). It
+// sends wsStateMsg updates so the TUI can reflect connection state. It
+// returns at once, after an errOccurredMsg, if the client isn't logged in;
+// otherwise it never returns, so call it in its own goroutine.
 func (c *ApiClient) ConnectAndListen(program *tea.Program) {
-	if c.User == nil || c.token == "" {
+	c.mu.RLock()
+	loggedIn := c.User != nil && c.token != ""
+	c.mu.RUnlock()
+	if !loggedIn {
 		program.Send(errOccurredMsg{fmt.Errorf("must be logged in to connect")})
 		return
 	}
 
+	backoff := wsInitialBackoff
+	for {
+		program.Send(wsStateMsg{client: c, state: WSConnecting})
+		connectedAt := time.Now()
+		// An ordinary disconnect here (network blip, server restart) is
+		// expected and already reflected via the wsStateMsg below and the
+		// header's connection label; the backoff loop reconnects on its
+		// own. Sending errOccurredMsg for it would strand the user on the
+		// fatal error screen with no way back, even while reconnection is
+		// succeeding in the background. errOccurredMsg is reserved for
+		// conditions ConnectAndListen can't recover from on its own, such
+		// as not being logged in at all (checked above).
+		_ = c.runConnection(program)
+		program.Send(wsStateMsg{client: c, state: WSDisconnected})
+
+		if time.Since(connectedAt) >= wsStableUptime {
+			backoff = wsInitialBackoff
+		}
+
+		select {
+		case <-c.reconnect:
+		case <-time.After(jitter(backoff)):
+		}
+
+		if backoff < wsMaxBackoff {
+			backoff *= 2
+			if backoff > wsMaxBackoff {
+				backoff = wsMaxBackoff
+			}
+		}
+	}
+}
+
+// jitter adds up to 20% random variance to d so that many clients
+// reconnecting around the same time don't retry in lockstep.
+func jitter(d time.Duration) time.Duration {
+	return d + time.Duration(rand.Int63n(int64(d)/5+1))
+}
+
+// runConnection dials the websocket, backfills any messages missed since
+// the last connection, and reads until the connection drops or
+// ForceReconnect closes it. It returns a non-nil error if the URL can't be
+// parsed, the dial fails, or the server closes the connection with an
+// unexpected close code; any other end of the connection returns nil.
+func (c *ApiClient) runConnection(program *tea.Program) error {
 	u, err := url.Parse(c.baseURL)
 	if err != nil {
-		program.Send(errOccurredMsg{err})
-		return
+		return err
 	}
 
 	if u.Scheme == "https" {
@@ -223,7 +579,7 @@ func (c *ApiClient) ConnectAndListen(program *tea.Program) {
 	u.Path = "/api/ws"
 	q := u.Query()
 	// FIX: Use the token for WebSocket authentication instead of user_id.
-	q.Set("token", c.token)
+	q.Set("token", c.currentToken())
 	u.RawQuery = q.Encode()
 
 	headers := http.Header{}
@@ -232,13 +588,13 @@ func (c *ApiClient) ConnectAndListen(program *tea.Program) {
 
 	conn, _, err := websocket.DefaultDialer.Dial(u.String(), headers)
 	if err != nil {
-		program.Send(errOccurredMsg{fmt.Errorf("websocket dial error: %w", err)})
-		return
+		return fmt.Errorf("websocket dial error: %w", err)
 	}
 	defer conn.Close()
 	c.wsConn = conn
 
-	// Ping-pong and message reading loops are unchanged...
+	program.Send(wsStateMsg{client: c, state: WSConnected})
+	c.backfillMissed(program)
 
 	// Ping handler to keep connection alive
 	conn.SetPingHandler(func(appData string) error {
@@ -246,33 +602,83 @@ func (c *ApiClient) ConnectAndListen(program *tea.Program) {
 	})
 
 	// Start ping loop
+	pingDone := make(chan struct{})
+	defer close(pingDone)
 	go func() {
 		ticker := time.NewTicker(25 * time.Second)
 		defer ticker.Stop()
 
 		for {
-			<-ticker.C
-			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				program.Send(errOccurredMsg{fmt.Errorf("websocket ping failed: %w", err)})
+			select {
+			case <-pingDone:
 				return
+			case <-ticker.C:
+				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+					return
+				}
 			}
 		}
 	}()
 
+	// A ForceReconnect while connected closes this connection; the signal is
+	// re-armed so the supervisor then skips its backoff wait.
+	go func() {
+		select {
+		case <-pingDone:
+		case <-c.reconnect:
+			c.ForceReconnect()
+			conn.Close()
+		}
+	}()
+
 	// Main message loop
 	for {
 		_, message, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				program.Send(errOccurredMsg{fmt.Errorf("websocket read error: %w", err)})
+				return fmt.Errorf("websocket read error: %w", err)
 			}
-			return
+			return nil
 		}
 
 		var wsMsgData WebSocketMessage
 		if err := json.Unmarshal(message, &wsMsgData); err != nil {
 			continue // Ignore malformed messages
 		}
-		program.Send(wsMsg{wsMsgData})
+		if wsMsgData.Event == "new_message" {
+			var newMsg Message
+			if err := json.Unmarshal(wsMsgData.Payload, &newMsg); err == nil {
+				c.mu.Lock()
+				c.lastMessageID[newMsg.ChannelID] = newMsg.ID
+				c.mu.Unlock()
+			}
+		}
+		program.Send(wsMsg{client: c, msg: wsMsgData})
 	}
-}
\ No newline at end of file
+}
+
+// backfillMissed re-fetches, per channel already seen, any messages newer
+// than the last one this client observed, so a reconnect doesn't silently
+// drop messages sent while disconnected.
+func (c *ApiClient) backfillMissed(program *tea.Program) {
+	c.mu.RLock()
+	sinceByChannel := make(map[int64]int64, len(c.lastMessageID))
+	for channelID, sinceID := range c.lastMessageID {
+		sinceByChannel[channelID] = sinceID
+	}
+	c.mu.RUnlock()
+
+	for channelID, sinceID := range sinceByChannel {
+		msgs, err := c.GetMessages(channelID, sinceID)
+		if err != nil {
+			continue
+		}
+		for _, msg := range msgs {
+			payload, err := json.Marshal(msg)
+			if err != nil {
+				continue
+			}
+			program.Send(wsMsg{client: c, msg: WebSocketMessage{Event: "new_message", Payload: payload}})
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package tui
+
+import (
+	"crypto/ecdh"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	pcrypto "prisma/tui/crypto"
+)
+
+// Command is a client-side slash command. Commands are handled entirely in
+// the TUI and never reach the server as ordinary chat content.
+type Command struct {
+	Name string
+	Help string
+	Run  func(*model, []string) tea.Cmd
+}
+
+var commandRegistry = map[string]*Command{}
+
+func registerCommand(c *Command) {
+	commandRegistry[c.Name] = c
+}
+
+func init() {
+	registerCommand(&Command{Name: "help", Help: "/help - list available commands", Run: runHelp})
+	registerCommand(&Command{Name: "nick", Help: "/nick <name> - change your display name", Run: runNick})
+	registerCommand(&Command{Name: "list", Help: "/list - show all channels and categories", Run: runList})
+	registerCommand(&Command{Name: "join", Help: "/join <#channel> - switch to a channel", Run: runJoin})
+	registerCommand(&Command{Name: "who", Help: "/who - show the full roster", Run: runWho})
+	registerCommand(&Command{Name: "me", Help: "/me <action> - send an action message", Run: runMe})
+	registerCommand(&Command{Name: "raw", Help: "/raw - toggle plain-text rendering", Run: runRaw})
+	registerCommand(&Command{Name: "reconnect", Help: "/reconnect - force the websocket to reconnect", Run: runReconnect})
+	registerCommand(&Command{Name: "upload", Help: "/upload <path> - upload and send a file", Run: runUpload})
+	registerCommand(&Command{Name: "verify", Help: "/verify <user> - show a user's key fingerprint", Run: runVerify})
+}
+
+// Messages emitted by commands that need to fetch data before updating the model.
+type nickChangedMsg struct{ name string }
+type listLoadedMsg struct{ cats []ChannelCategory }
+type rosterLoadedMsg struct{ users []User }
+
+// commandErrorMsg reports a slash command failing against the server (a
+// rejected nick, a timed-out roster fetch, and so on) as a non-fatal system
+// message, as opposed to errOccurredMsg which takes the whole TUI down. A
+// command failing is routine and recoverable; it shouldn't strand the user
+// on the error screen.
+type commandErrorMsg struct{ err error }
+
+// dispatchCommand parses a leading "/" input line and runs the matching
+// command. ok is false if the line isn't a slash command at all, in which
+// case the caller should treat it as a normal message.
+func dispatchCommand(m *model, line string) (cmd tea.Cmd, ok bool) {
+	if !strings.HasPrefix(line, "/") {
+		return nil, false
+	}
+	fields := strings.Fields(line[1:])
+	if len(fields) == 0 {
+		return nil, false
+	}
+	name, args := fields[0], fields[1:]
+	c, known := commandRegistry[name]
+	if !known {
+		m.pushSystemMessage(fmt.Sprintf("unknown command: /%s", name))
+		return nil, true
+	}
+	return c.Run(m, args), true
+}
+
+// pushSystemMessage appends a locally-generated, system-styled message to
+// the active channel without a server round trip.
+func (m *model) pushSystemMessage(text string) {
+	if len(m.channels) == 0 {
+		return
+	}
+	ch := m.channels[m.activeTabIndex]
+	m.messages[ch.ID] = append(m.messages[ch.ID], Message{
+		ID:        -1,
+		ChannelID: ch.ID,
+		Username:  "system",
+		Content:   text,
+		Type:      "system",
+		CreatedAt: time.Now(),
+	})
+	if m.viewportReady {
+		m.chatViewport.SetContent(m.renderMessagesContent(ch.ID))
+		m.chatViewport.GotoBottom()
+	}
+}
+
+// openModal shows title/body in the full-screen overlay until the user
+// presses Esc.
+func (m *model) openModal(title, body string) {
+	m.showModal = true
+	m.modalTitle = title
+	m.modalBody = body
+}
+
+func runHelp(m *model, args []string) tea.Cmd {
+	var names []string
+	for name := range commandRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	sb.WriteString("Available commands:")
+	for _, name := range names {
+		sb.WriteString("\n  " + commandRegistry[name].Help)
+	}
+	m.pushSystemMessage(sb.String())
+	return nil
+}
+
+func runNick(m *model, args []string) tea.Cmd {
+	if len(args) != 1 {
+		m.pushSystemMessage("usage: /nick <name>")
+		return nil
+	}
+	newNick := args[0]
+	client := m.client
+	return func() tea.Msg {
+		if err := client.ChangeNick(newNick); err != nil {
+			return commandErrorMsg{fmt.Errorf("nick change failed: %w", err)}
+		}
+		return nickChangedMsg{newNick}
+	}
+}
+
+func runList(m *model, args []string) tea.Cmd {
+	client := m.client
+	return func() tea.Msg {
+		cats, err := client.GetCategories()
+		if err != nil {
+			return commandErrorMsg{fmt.Errorf("list failed: %w", err)}
+		}
+		return listLoadedMsg{cats}
+	}
+}
+
+func runJoin(m *model, args []string) tea.Cmd {
+	if len(args) != 1 {
+		m.pushSystemMessage("usage: /join <#channel>")
+		return nil
+	}
+	name := strings.TrimPrefix(args[0], "#")
+	for i, ch := range m.channels {
+		if ch.Name != name {
+			continue
+		}
+		m.activeTabIndex = i
+		if _, ok := m.messages[ch.ID]; !ok {
+			return fetchHistoryCmd(m.client, ch.ID)
+		}
+		m.chatViewport.SetContent(m.renderMessagesContent(ch.ID))
+		m.chatViewport.GotoBottom()
+		return nil
+	}
+	m.pushSystemMessage(fmt.Sprintf("no such channel: #%s", name))
+	return nil
+}
+
+func runWho(m *model, args []string) tea.Cmd {
+	client := m.client
+	return func() tea.Msg {
+		users, err := client.GetUsers()
+		if err != nil {
+			return commandErrorMsg{fmt.Errorf("who failed: %w", err)}
+		}
+		return rosterLoadedMsg{users}
+	}
+}
+
+func runRaw(m *model, args []string) tea.Cmd {
+	m.rawMode = !m.rawMode
+	if m.rawMode {
+		m.renderer = plainRenderer{}
+		m.pushSystemMessage("raw mode enabled")
+	} else {
+		m.renderer = newGlamourRenderer()
+		m.pushSystemMessage("raw mode disabled")
+	}
+	if m.viewportReady && len(m.channels) > 0 {
+		ch := m.channels[m.activeTabIndex]
+		m.chatViewport.SetContent(m.renderMessagesContent(ch.ID))
+	}
+	return nil
+}
+
+func runReconnect(m *model, args []string) tea.Cmd {
+	m.client.ForceReconnect()
+	m.pushSystemMessage("forcing websocket reconnect...")
+	return nil
+}
+
+func runVerify(m *model, args []string) tea.Cmd {
+	if len(args) != 1 {
+		m.pushSystemMessage("usage: /verify <user>")
+		return nil
+	}
+	if m.keystore == nil {
+		m.pushSystemMessage("identity key not ready yet")
+		return nil
+	}
+	username := args[0]
+	client := m.client
+	ks := m.keystore
+	return func() tea.Msg {
+		users, err := client.GetUsers()
+		if err != nil {
+			return commandErrorMsg{fmt.Errorf("verify failed: %w", err)}
+		}
+		var peer *User
+		for i := range users {
+			if users[i].Username == username {
+				peer = &users[i]
+				break
+			}
+		}
+		if peer == nil {
+			return cryptoErrorMsg{fmt.Errorf("no such user: %s", username)}
+		}
+		pubBytes, err := client.GetPublicKey(peer.ID)
+		if err != nil {
+			return cryptoErrorMsg{fmt.Errorf("fetching public key: %w", err)}
+		}
+		pub, err := ecdh.X25519().NewPublicKey(pubBytes)
+		if err != nil {
+			return cryptoErrorMsg{fmt.Errorf("invalid public key for %s: %w", username, err)}
+		}
+		changed, pinErr := ks.Pin(peer.ID, pub)
+		text := fmt.Sprintf("%s fingerprint: %s", username, pcrypto.Fingerprint(pub))
+		if pinErr != nil {
+			text += fmt.Sprintf(" (warning: failed to persist pin: %v)", pinErr)
+		}
+		if changed {
+			text = "WARNING: fingerprint changed! " + text
+		}
+		return verifyResultMsg{text}
+	}
+}
+
+func runMe(m *model, args []string) tea.Cmd {
+	if len(args) == 0 {
+		m.pushSystemMessage("usage: /me <action>")
+		return nil
+	}
+	if len(m.channels) == 0 {
+		return nil
+	}
+	action := strings.Join(args, " ")
+	ch := m.channels[m.activeTabIndex]
+	client := m.client
+	return func() tea.Msg {
+		if err := client.SendMessage(ch.ID, action, "action", nil); err != nil {
+			return commandErrorMsg{fmt.Errorf("send failed: %w", err)}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,104 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// hkdfInfo domain-separates the DM key derivation from any other use of
+// this X25519 identity.
+var hkdfInfo = []byte("prisma-dm-v1")
+
+// EncryptedPayload is the sealed form of a single DM: the ciphertext, the
+// random nonce used to derive its key, and the sender's one-time ephemeral
+// public key the recipient needs to re-derive the shared secret.
+type EncryptedPayload struct {
+	Ciphertext   []byte
+	Nonce        []byte
+	EphemeralPub []byte
+}
+
+// Encrypt derives a per-message AES-256 key from both a fresh ephemeral
+// X25519 keypair and senderPriv (via HKDF-SHA256 over their ECDH outputs
+// with recipientPub, plus a random nonce), then seals plaintext with
+// AES-256-GCM. Mixing in the static-static ECDH term authenticates the
+// sender: only the holder of senderPriv can produce a key the recipient's
+// Decrypt will accept for the claimed sender's pinned public key.
+func Encrypt(senderPriv *ecdh.PrivateKey, recipientPub *ecdh.PublicKey, plaintext []byte) (*EncryptedPayload, error) {
+	ephPriv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	ephShared, err := ephPriv.ECDH(recipientPub)
+	if err != nil {
+		return nil, err
+	}
+	staticShared, err := senderPriv.ECDH(recipientPub)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, 12)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(append(ephShared, staticShared...), nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EncryptedPayload{
+		Ciphertext:   gcm.Seal(nil, nonce, plaintext, nil),
+		Nonce:        nonce,
+		EphemeralPub: ephPriv.PublicKey().Bytes(),
+	}, nil
+}
+
+// Decrypt reverses Encrypt using the recipient's long-term private key and
+// the claimed sender's long-term public key. X25519 ECDH is commutative, so
+// priv.ECDH(senderPub) only reproduces Encrypt's static-static term when
+// senderPub is the true sender's key that matches senderPriv; a forged
+// envelope from anyone else fails AES-GCM authentication here instead of
+// decrypting under the wrong identity.
+func Decrypt(priv *ecdh.PrivateKey, senderPub *ecdh.PublicKey, payload *EncryptedPayload) ([]byte, error) {
+	ephPub, err := ecdh.X25519().NewPublicKey(payload.EphemeralPub)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral public key: %w", err)
+	}
+	ephShared, err := priv.ECDH(ephPub)
+	if err != nil {
+		return nil, err
+	}
+	staticShared, err := priv.ECDH(senderPub)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(append(ephShared, staticShared...), payload.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Open(nil, payload.Nonce, payload.Ciphertext, nil)
+}
+
+// newGCM derives a 32-byte AES-256 key from the combined shared secret and
+// nonce via HKDF-SHA256 and builds the AES-GCM AEAD from it.
+func newGCM(shared, nonce []byte) (cipher.AEAD, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, nonce, hkdfInfo), key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,72 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"testing"
+)
+
+func genKey(t *testing.T) *ecdh.PrivateKey {
+	t.Helper()
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	return priv
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	sender := genKey(t)
+	recipient := genKey(t)
+	plaintext := []byte("hello from the other side")
+
+	payload, err := Encrypt(sender, recipient.PublicKey(), plaintext)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	got, err := Decrypt(recipient, sender.PublicKey(), payload)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("round trip mismatch: got %q, want %q", got, plaintext)
+	}
+}
+
+// TestDecryptRejectsForgedSender ensures a message encrypted by someone
+// other than the claimed sender fails to decrypt, rather than silently
+// succeeding against the wrong identity.
+func TestDecryptRejectsForgedSender(t *testing.T) {
+	forger := genKey(t)
+	claimedSender := genKey(t)
+	recipient := genKey(t)
+
+	payload, err := Encrypt(forger, recipient.PublicKey(), []byte("spoofed"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	if _, err := Decrypt(recipient, claimedSender.PublicKey(), payload); err == nil {
+		t.Fatal("expected Decrypt to reject a payload encrypted by an unrelated sender")
+	}
+}
+
+// TestDecryptRejectsWrongRecipient ensures a message encrypted for one
+// recipient can't be read by a different party, even one who knows the
+// real sender's public key.
+func TestDecryptRejectsWrongRecipient(t *testing.T) {
+	sender := genKey(t)
+	recipient := genKey(t)
+	eavesdropper := genKey(t)
+
+	payload, err := Encrypt(sender, recipient.PublicKey(), []byte("for your eyes only"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	if _, err := Decrypt(eavesdropper, sender.PublicKey(), payload); err == nil {
+		t.Fatal("expected Decrypt to fail for a non-recipient private key")
+	}
+}
@@ -0,0 +1,118 @@
+// Package crypto implements the end-to-end encryption used for DM channels:
+// a long-term X25519 identity, on-disk TOFU fingerprint pinning, and an
+// authenticated X25519 (ephemeral-static + static-static) + HKDF-SHA256 +
+// AES-256-GCM scheme used to seal individual messages so only the intended
+// recipient can read them and only the pinned sender could have sent them.
+package crypto
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Keystore holds this client's long-term X25519 identity and the pinned
+// public-key fingerprints of peers it has contacted (trust-on-first-use).
+// pinned is persisted to pinPath so a fingerprint change is still caught
+// after a restart, not just within a single run.
+type Keystore struct {
+	keyPath    string
+	pinPath    string
+	PrivateKey *ecdh.PrivateKey
+	pinned     map[int64]string // userID -> hex SHA-256 fingerprint
+}
+
+// LoadOrCreate loads the identity key and previously pinned peer
+// fingerprints from $XDG_CONFIG_HOME/prisma/{identity.key,pinned.json},
+// generating a new X25519 keypair (and an empty pin set) on first run.
+func LoadOrCreate() (*Keystore, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+	keyPath := filepath.Join(dir, "identity.key")
+	pinPath := filepath.Join(dir, "pinned.json")
+
+	if data, err := os.ReadFile(keyPath); err == nil {
+		priv, err := ecdh.X25519().NewPrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity key at %s: %w", keyPath, err)
+		}
+		pinned, err := loadPinned(pinPath)
+		if err != nil {
+			return nil, err
+		}
+		return &Keystore{keyPath: keyPath, pinPath: pinPath, PrivateKey: priv, pinned: pinned}, nil
+	}
+
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyPath, priv.Bytes(), 0600); err != nil {
+		return nil, err
+	}
+	return &Keystore{keyPath: keyPath, pinPath: pinPath, PrivateKey: priv, pinned: make(map[int64]string)}, nil
+}
+
+// loadPinned reads the pin set from path, returning an empty set (not an
+// error) if it doesn't exist yet.
+func loadPinned(path string) (map[int64]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return make(map[int64]string), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pinned := make(map[int64]string)
+	if err := json.Unmarshal(data, &pinned); err != nil {
+		return nil, fmt.Errorf("invalid pin store at %s: %w", path, err)
+	}
+	return pinned, nil
+}
+
+func configDir() (string, error) {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "prisma"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "prisma"), nil
+}
+
+// Fingerprint returns the hex SHA-256 fingerprint of a public key, for
+// out-of-band comparison via /verify.
+func Fingerprint(pub *ecdh.PublicKey) string {
+	sum := sha256.Sum256(pub.Bytes())
+	return hex.EncodeToString(sum[:])
+}
+
+// Pin records userID's public-key fingerprint on first contact (TOFU),
+// persists the updated pin set to pinPath, and reports true if a
+// previously-pinned fingerprint has since changed.
+func (k *Keystore) Pin(userID int64, pub *ecdh.PublicKey) (changed bool, err error) {
+	fp := Fingerprint(pub)
+	prev, known := k.pinned[userID]
+	changed = known && prev != fp
+	k.pinned[userID] = fp
+	return changed, k.savePinned()
+}
+
+func (k *Keystore) savePinned() error {
+	data, err := json.Marshal(k.pinned)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(k.pinPath, data, 0600)
+}
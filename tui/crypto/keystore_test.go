@@ -0,0 +1,55 @@
+package crypto
+
+import (
+	"testing"
+)
+
+func TestLoadOrCreatePersistsIdentity(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	first, err := LoadOrCreate()
+	if err != nil {
+		t.Fatalf("LoadOrCreate (first run): %v", err)
+	}
+
+	second, err := LoadOrCreate()
+	if err != nil {
+		t.Fatalf("LoadOrCreate (second run): %v", err)
+	}
+
+	if !first.PrivateKey.Equal(second.PrivateKey) {
+		t.Fatal("identity key was not persisted across LoadOrCreate calls")
+	}
+}
+
+// TestPinPersistsAcrossRestart ensures a pinned fingerprint survives a
+// process restart (a new Keystore loaded from the same config dir), so a
+// key change between sessions is still caught.
+func TestPinPersistsAcrossRestart(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	ks, err := LoadOrCreate()
+	if err != nil {
+		t.Fatalf("LoadOrCreate: %v", err)
+	}
+	peerKey := genKey(t)
+	if changed, err := ks.Pin(42, peerKey.PublicKey()); err != nil {
+		t.Fatalf("Pin: %v", err)
+	} else if changed {
+		t.Fatal("first pin of a peer should never report changed")
+	}
+
+	restarted, err := LoadOrCreate()
+	if err != nil {
+		t.Fatalf("LoadOrCreate (restart): %v", err)
+	}
+
+	newPeerKey := genKey(t)
+	changed, err := restarted.Pin(42, newPeerKey.PublicKey())
+	if err != nil {
+		t.Fatalf("Pin (after restart): %v", err)
+	}
+	if !changed {
+		t.Fatal("expected a changed fingerprint to be detected after reloading a persisted pin")
+	}
+}
@@ -0,0 +1,215 @@
+package tui
+
+import (
+	"crypto/ecdh"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	pcrypto "prisma/tui/crypto"
+)
+
+// encryptedEnvelope is the wire format for an encrypted DM: sent
+// base64-encoded-per-field as Message.Content, with Enc=1 marking it so
+// renderMessagesContent knows to decrypt before display.
+type encryptedEnvelope struct {
+	Enc          int    `json:"enc"`
+	Ciphertext   string `json:"ciphertext"`
+	Nonce        string `json:"nonce"`
+	EphemeralPub string `json:"ephemeral_pub"`
+}
+
+// keystoreReadyMsg carries the loaded identity keystore into the model once
+// loadKeystoreCmd finishes.
+type keystoreReadyMsg struct{ ks *pcrypto.Keystore }
+
+// cryptoErrorMsg reports a non-fatal crypto failure as a system message,
+// as opposed to errOccurredMsg which takes the whole TUI down.
+type cryptoErrorMsg struct{ err error }
+
+// verifyResultMsg carries the /verify command's fingerprint output.
+type verifyResultMsg struct{ text string }
+
+// loadKeystoreCmd loads (or creates) the long-term identity key. It is
+// shared across every server profile; publishing it to a given server's
+// account happens separately via publishKeyCmd.
+func loadKeystoreCmd() tea.Cmd {
+	return func() tea.Msg {
+		ks, err := pcrypto.LoadOrCreate()
+		if err != nil {
+			return cryptoErrorMsg{fmt.Errorf("failed to load identity key: %w", err)}
+		}
+		return keystoreReadyMsg{ks}
+	}
+}
+
+// publishKeyCmd publishes the already-loaded identity key to client, so
+// that server's account can receive DMs encrypted to this identity.
+func publishKeyCmd(client *ApiClient, ks *pcrypto.Keystore) tea.Cmd {
+	return func() tea.Msg {
+		if err := client.PublishPublicKey(ks.PrivateKey.PublicKey().Bytes()); err != nil {
+			return cryptoErrorMsg{fmt.Errorf("failed to publish public key: %w", err)}
+		}
+		return nil
+	}
+}
+
+// dmPeer returns the user ID a channel is a DM with, if any.
+func (m *model) dmPeer(channelID int64) (int64, bool) {
+	for _, ch := range m.channels {
+		if ch.ID == channelID && ch.Encrypted {
+			return ch.PeerID, true
+		}
+	}
+	return 0, false
+}
+
+// encryptForChannel encrypts plaintext for channelID's DM peer and returns
+// the JSON envelope to send as Message.Content. The peer's public key must
+// already be cached by peerKeyFetchCmds; this never fetches it inline, so a
+// slow key-server round trip can't block the keystroke that triggered it.
+func (m *model) encryptForChannel(channelID int64, plaintext string) (string, error) {
+	if m.keystore == nil {
+		return "", fmt.Errorf("identity key not ready yet")
+	}
+	peerID, ok := m.dmPeer(channelID)
+	if !ok {
+		return "", fmt.Errorf("channel %d is not a DM", channelID)
+	}
+
+	entry, cached := m.peerKeyCache[peerID]
+	if !cached {
+		return "", fmt.Errorf("peer key not ready yet, try again in a moment")
+	}
+	if entry.err != nil {
+		return "", entry.err
+	}
+
+	payload, err := pcrypto.Encrypt(m.keystore.PrivateKey, entry.pub, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	out, err := json.Marshal(encryptedEnvelope{
+		Enc:          1,
+		Ciphertext:   base64.StdEncoding.EncodeToString(payload.Ciphertext),
+		Nonce:        base64.StdEncoding.EncodeToString(payload.Nonce),
+		EphemeralPub: base64.StdEncoding.EncodeToString(payload.EphemeralPub),
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// peerKeyCacheEntry holds the outcome of a previous fetchPeerKeyCmd call,
+// keyed by user ID, so the same peer's key is never fetched (and TOFU
+// re-checked) on every render.
+type peerKeyCacheEntry struct {
+	pub *ecdh.PublicKey
+	err error
+}
+
+// peerKeyLoadedMsg reports the result of a background fetch started by
+// fetchPeerKeyCmd.
+type peerKeyLoadedMsg struct {
+	peerID  int64
+	pub     *ecdh.PublicKey
+	changed bool
+	pinErr  error
+	err     error
+}
+
+// fetchPeerKeyCmd fetches and TOFU-pins peerID's public key off the UI
+// goroutine, as a tea.Cmd, so a slow key-server round trip can't block the
+// Bubble Tea event loop the way fetching inline from render or send did.
+func fetchPeerKeyCmd(client *ApiClient, ks *pcrypto.Keystore, peerID int64) tea.Cmd {
+	return func() tea.Msg {
+		pubBytes, err := client.GetPublicKey(peerID)
+		if err != nil {
+			return peerKeyLoadedMsg{peerID: peerID, err: fmt.Errorf("fetching public key: %w", err)}
+		}
+		pub, err := ecdh.X25519().NewPublicKey(pubBytes)
+		if err != nil {
+			return peerKeyLoadedMsg{peerID: peerID, err: fmt.Errorf("invalid public key for user %d: %w", peerID, err)}
+		}
+		changed, pinErr := ks.Pin(peerID, pub)
+		return peerKeyLoadedMsg{peerID: peerID, pub: pub, changed: changed, pinErr: pinErr}
+	}
+}
+
+// peerKeyFetchCmds ensures channelID's DM peer's public key is cached (or a
+// fetch is already in flight), mirroring attachmentFetchCmds for inline
+// images. Call this whenever a channel might be rendered or sent to.
+func (m *model) peerKeyFetchCmds(channelID int64) []tea.Cmd {
+	if m.keystore == nil {
+		return nil
+	}
+	peerID, ok := m.dmPeer(channelID)
+	if !ok {
+		return nil
+	}
+	if _, cached := m.peerKeyCache[peerID]; cached {
+		return nil
+	}
+	if m.pendingPeerKeys[peerID] {
+		return nil
+	}
+	m.pendingPeerKeys[peerID] = true
+	return []tea.Cmd{fetchPeerKeyCmd(m.client, m.keystore, peerID)}
+}
+
+// decryptContent reverses encryptForChannel for a message received on an
+// encrypted DM channel. ok is false if the channel isn't encrypted, or
+// content isn't a well-formed encrypted envelope, in which case it should
+// be displayed as-is rather than treated as an undecryptable encrypted
+// message. Decryption uses the channel's pinned peer as the claimed
+// sender's long-term key, so a forged or relabeled envelope from anyone
+// else fails to decrypt instead of rendering as verified plaintext.
+//
+// This is called from renderMessagesContent, a pure render function, so it
+// only ever reads from peerKeyCache; peerKeyFetchCmds is what keeps that
+// cache populated, off the render path.
+func (m *model) decryptContent(msg Message, channelEncrypted bool) (plaintext string, ok bool) {
+	if !channelEncrypted {
+		return "", false
+	}
+	var env encryptedEnvelope
+	if err := json.Unmarshal([]byte(msg.Content), &env); err != nil || env.Enc != 1 {
+		return "", false
+	}
+	if m.keystore == nil {
+		return "[identity key not ready]", true
+	}
+
+	peerID, isDM := m.dmPeer(msg.ChannelID)
+	if !isDM {
+		return "[not a DM channel]", true
+	}
+	entry, cached := m.peerKeyCache[peerID]
+	if !cached {
+		return "[fetching sender key...]", true
+	}
+	if entry.err != nil {
+		return fmt.Sprintf("[unable to fetch sender key: %v]", entry.err), true
+	}
+	senderPub := entry.pub
+
+	ciphertext, cerr := base64.StdEncoding.DecodeString(env.Ciphertext)
+	nonce, nerr := base64.StdEncoding.DecodeString(env.Nonce)
+	ephPub, eerr := base64.StdEncoding.DecodeString(env.EphemeralPub)
+	if cerr != nil || nerr != nil || eerr != nil {
+		return "[malformed encrypted message]", true
+	}
+
+	plain, err := pcrypto.Decrypt(m.keystore.PrivateKey, senderPub, &pcrypto.EncryptedPayload{
+		Ciphertext:   ciphertext,
+		Nonce:        nonce,
+		EphemeralPub: ephPub,
+	})
+	if err != nil {
+		return "[unable to decrypt message]", true
+	}
+	return string(plain), true
+}
@@ -0,0 +1,146 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/zalando/go-keyring"
+	"gopkg.in/yaml.v3"
+)
+
+// keyringService namespaces this client's entries in the OS keyring.
+const keyringService = "prisma-cli"
+
+// Profile is one saved server connection.
+type Profile struct {
+	Name     string `yaml:"name"`
+	BaseURL  string `yaml:"base_url"`
+	Username string `yaml:"username"`
+	// Token is only persisted here when the OS keyring is unavailable;
+	// otherwise it lives in the keyring and this is left empty on disk.
+	Token    string    `yaml:"token,omitempty"`
+	LastUsed time.Time `yaml:"last_used,omitempty"`
+}
+
+// ProfileStore loads and saves the server profile list at
+// $XDG_CONFIG_HOME/prisma/servers.yaml, storing tokens via the OS keyring
+// when available and falling back to a 0600 plaintext file otherwise.
+type ProfileStore struct {
+	path     string
+	profiles []Profile
+}
+
+// NewProfileStore loads the profile list, creating an empty one if
+// servers.yaml doesn't exist yet.
+func NewProfileStore() (*ProfileStore, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+	ps := &ProfileStore{path: filepath.Join(dir, "servers.yaml")}
+	if err := ps.load(); err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+func (ps *ProfileStore) load() error {
+	data, err := os.ReadFile(ps.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var profiles []Profile
+	if err := yaml.Unmarshal(data, &profiles); err != nil {
+		return fmt.Errorf("parsing %s: %w", ps.path, err)
+	}
+	ps.profiles = profiles
+	return nil
+}
+
+func (ps *ProfileStore) save() error {
+	if err := os.MkdirAll(filepath.Dir(ps.path), 0700); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(ps.profiles)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ps.path, data, 0600)
+}
+
+// Profiles returns all saved profiles, most-recently-used first.
+func (ps *ProfileStore) Profiles() []Profile {
+	out := make([]Profile, len(ps.profiles))
+	copy(out, ps.profiles)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].LastUsed.After(out[j].LastUsed) })
+	return out
+}
+
+// Find returns the saved profile with the given name, if any.
+func (ps *ProfileStore) Find(name string) (Profile, bool) {
+	for _, p := range ps.profiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Profile{}, false
+}
+
+// Upsert saves or updates a profile by name. Its token is stored in the OS
+// keyring when possible, falling back to the plaintext servers.yaml copy.
+func (ps *ProfileStore) Upsert(p Profile) error {
+	token := p.Token
+	p.Token = ""
+	if err := keyring.Set(keyringService, p.Name, token); err != nil {
+		p.Token = token // plaintext fallback, written at 0600 below
+	}
+
+	for i := range ps.profiles {
+		if ps.profiles[i].Name == p.Name {
+			ps.profiles[i] = p
+			return ps.save()
+		}
+	}
+	ps.profiles = append(ps.profiles, p)
+	return ps.save()
+}
+
+// Token returns a profile's saved token, preferring the OS keyring and
+// falling back to the plaintext copy in servers.yaml.
+func (ps *ProfileStore) Token(name string) (string, error) {
+	if tok, err := keyring.Get(keyringService, name); err == nil {
+		return tok, nil
+	}
+	if p, ok := ps.Find(name); ok && p.Token != "" {
+		return p.Token, nil
+	}
+	return "", fmt.Errorf("no token stored for profile %q", name)
+}
+
+// Touch updates a profile's last-used timestamp so it sorts first next time.
+func (ps *ProfileStore) Touch(name string, when time.Time) error {
+	for i := range ps.profiles {
+		if ps.profiles[i].Name == name {
+			ps.profiles[i].LastUsed = when
+			return ps.save()
+		}
+	}
+	return fmt.Errorf("no such profile: %s", name)
+}
+
+func configDir() (string, error) {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "prisma"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "prisma"), nil
+}
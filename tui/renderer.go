@@ -0,0 +1,63 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+)
+
+// MessageRenderer turns a message's content into the string drawn in the
+// viewport. width is the wrap width at render time, so callers must
+// re-render when it changes.
+type MessageRenderer interface {
+	Render(msg Message, width int) string
+}
+
+// plainRenderer is the original, unstyled passthrough. It backs the /raw
+// toggle for accessibility and for terminals that can't render Glamour's
+// ANSI output well.
+type plainRenderer struct{}
+
+func (plainRenderer) Render(msg Message, width int) string {
+	return msg.Content
+}
+
+// glamourRenderer renders Markdown (bold, italic, lists, links, and fenced
+// code blocks with Chroma syntax highlighting) via Glamour, wrapped to the
+// viewport width. Output is cached per message ID so scrolling doesn't
+// re-render the whole buffer; a cache entry is invalidated only when the
+// requested width or the message content no longer matches the one it was
+// rendered from (e.g. an edit, or a DM placeholder replaced by plaintext).
+type glamourRenderer struct {
+	cache map[int64]glamourCacheEntry
+}
+
+type glamourCacheEntry struct {
+	width   int
+	content string
+	output  string
+}
+
+func newGlamourRenderer() *glamourRenderer {
+	return &glamourRenderer{cache: make(map[int64]glamourCacheEntry)}
+}
+
+func (r *glamourRenderer) Render(msg Message, width int) string {
+	if entry, ok := r.cache[msg.ID]; ok && entry.width == width && entry.content == msg.Content {
+		return entry.output
+	}
+
+	out := msg.Content
+	tr, err := glamour.NewTermRenderer(
+		glamour.WithAutoStyle(),
+		glamour.WithWordWrap(width),
+	)
+	if err == nil {
+		if rendered, rerr := tr.Render(msg.Content); rerr == nil {
+			out = strings.TrimRight(rendered, "\n")
+		}
+	}
+
+	r.cache[msg.ID] = glamourCacheEntry{width: width, content: msg.Content, output: out}
+	return out
+}
@@ -0,0 +1,234 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ServerSession is one logged-in server connection: its ApiClient plus the
+// channel/message/presence state for that server, keyed in model.servers
+// by profile name. Exactly one session is "active" at a time; its state is
+// mirrored into the model's top-level fields for fast rendering and synced
+// back via snapshotActive before switching away.
+type ServerSession struct {
+	ID            string
+	Profile       Profile
+	Client        *ApiClient
+	Channels      []Channel
+	Messages      map[int64][]Message
+	OnlineUsers   map[string]bool
+	ActiveTab     int
+	WSState       WSState
+	LastChannelID int64
+}
+
+func newServerSession(id string, profile Profile, client *ApiClient) *ServerSession {
+	return &ServerSession{
+		ID:          id,
+		Profile:     profile,
+		Client:      client,
+		Messages:    make(map[int64][]Message),
+		OnlineUsers: make(map[string]bool),
+	}
+}
+
+// ProgramReadyMsg delivers the running *tea.Program back into the model so
+// later-connected servers (via the picker or Ctrl+S switcher) can pass it
+// to ConnectAndListen. main.go sends this once, right after constructing
+// the program.
+type ProgramReadyMsg struct{ Program *tea.Program }
+
+// serverIDForClient finds which ServerSession owns client, so inbound
+// websocket events (tagged with their originating client) can be routed to
+// the right session even when that server isn't the one currently shown.
+func (m *model) serverIDForClient(client *ApiClient) (string, bool) {
+	for id, s := range m.servers {
+		if s.Client == client {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// pickerEntry is one row of the server picker/switcher list.
+type pickerEntry struct {
+	Name      string
+	Connected bool
+}
+
+// buildPickerEntries lists saved profiles, most-recently-used first,
+// flagging ones that already have an open ServerSession.
+func (m *model) buildPickerEntries() []pickerEntry {
+	if m.profileStore == nil {
+		return nil
+	}
+	var entries []pickerEntry
+	for _, p := range m.profileStore.Profiles() {
+		_, connected := m.servers[p.Name]
+		entries = append(entries, pickerEntry{Name: p.Name, Connected: connected})
+	}
+	return entries
+}
+
+// snapshotActive copies the model's live chat fields back into the active
+// ServerSession before switching away from it.
+func (m *model) snapshotActive() {
+	s, ok := m.servers[m.activeServer]
+	if !ok {
+		return
+	}
+	s.Channels = m.channels
+	s.Messages = m.messages
+	s.OnlineUsers = m.onlineUsers
+	s.ActiveTab = m.activeTabIndex
+	s.WSState = m.wsState
+	s.LastChannelID = m.lastChannelID
+}
+
+// activateServer loads a ServerSession's cached state into the model's
+// live chat fields, snapshotting whatever was active beforehand.
+func (m *model) activateServer(id string) {
+	m.snapshotActive()
+	s := m.servers[id]
+	m.activeServer = id
+	m.client = s.Client
+	m.channels = s.Channels
+	m.messages = s.Messages
+	m.onlineUsers = s.OnlineUsers
+	m.activeTabIndex = s.ActiveTab
+	m.wsState = s.WSState
+	m.lastChannelID = s.LastChannelID
+}
+
+// selectServer activates an already-open session, or logs into a saved
+// profile and opens a new one, then connects its websocket.
+func (m *model) selectServer(name string) tea.Cmd {
+	if _, ok := m.servers[name]; ok {
+		m.activateServer(name)
+		m.showSwitcher = false
+		s := m.servers[name]
+		if len(s.Channels) == 0 {
+			m.state = stateLoading
+			return fetchInitialDataCmd(s.Client)
+		}
+		m.state = stateChatting
+		if m.viewportReady {
+			m.chatViewport.SetContent(m.renderMessagesContent(s.LastChannelID))
+			m.chatViewport.GotoBottom()
+		}
+		return nil
+	}
+
+	profile, ok := m.profileStore.Find(name)
+	if !ok {
+		m.pushSystemMessage(fmt.Sprintf("no such profile: %s", name))
+		return nil
+	}
+	token, err := m.profileStore.Token(name)
+	if err != nil {
+		m.pushSystemMessage(fmt.Sprintf("no saved credentials for %s: %v", name, err))
+		return nil
+	}
+
+	client := NewApiClient(profile.BaseURL)
+	client.SetSession(profile.Username, token)
+
+	session := newServerSession(name, profile, client)
+	m.servers[name] = session
+	m.activateServer(name)
+	m.showSwitcher = false
+	m.state = stateLoading
+	_ = m.profileStore.Touch(name, time.Now())
+
+	cmds := []tea.Cmd{fetchInitialDataCmd(client), connectServerCmd(client, m.program)}
+	if m.keystore != nil {
+		cmds = append(cmds, publishKeyCmd(client, m.keystore))
+	}
+	return tea.Batch(cmds...)
+}
+
+// connectServerCmd runs a server's websocket supervisor. It never returns
+// while the server is reachable, so its goroutine lives for the program's
+// lifetime, same as the single-server ConnectAndListen call this replaces.
+func connectServerCmd(client *ApiClient, program *tea.Program) tea.Cmd {
+	return func() tea.Msg {
+		if program != nil {
+			client.ConnectAndListen(program)
+		}
+		return nil
+	}
+}
+
+// renderPicker draws the server picker (at startup) or switcher (Ctrl+S)
+// list, with a cursor on the currently-highlighted entry.
+func (m model) renderPicker(title string) string {
+	entries := m.buildPickerEntries()
+	var sb strings.Builder
+	sb.WriteString(title + "\n\n")
+	if len(entries) == 0 {
+		sb.WriteString("(no saved servers -- run with --add-server to create one)\n")
+	}
+	for i, e := range entries {
+		cursor := "  "
+		if i == m.pickerIndex {
+			cursor = "> "
+		}
+		status := ""
+		if e.Connected {
+			status = " (connected)"
+		}
+		sb.WriteString(cursor + e.Name + status + "\n")
+	}
+	if m.showSwitcher {
+		sb.WriteString("\n(Up/Down to choose, Enter to connect, Esc to close, Ctrl+C to quit)")
+	} else {
+		sb.WriteString("\n(Up/Down to choose, Enter to connect, Esc/Ctrl+C to quit)")
+	}
+
+	height := m.height - 3
+	if height < 1 {
+		height = 1
+	}
+	width := m.width - 4
+	if width < 1 {
+		width = 1
+	}
+	return userListStyle.Width(width).Height(height).Render(sb.String())
+}
+
+// updatePicker handles key input while the startup server picker or the
+// Ctrl+S switcher overlay is showing.
+func (m model) updatePicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	entries := m.buildPickerEntries()
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		if m.showSwitcher {
+			m.showSwitcher = false
+			return m, nil
+		}
+		return m, tea.Quit
+	case tea.KeyUp:
+		if m.pickerIndex > 0 {
+			m.pickerIndex--
+		}
+	case tea.KeyDown:
+		if m.pickerIndex < len(entries)-1 {
+			m.pickerIndex++
+		}
+	case tea.KeyEnter:
+		if len(entries) == 0 {
+			return m, nil
+		}
+		if m.pickerIndex >= len(entries) {
+			m.pickerIndex = len(entries) - 1
+		}
+		cmd := m.selectServer(entries[m.pickerIndex].Name)
+		return m, cmd
+	}
+	return m, nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	pcrypto "prisma/tui/crypto"
 )
 
 // Styles (unchanged)
@@ -25,6 +26,8 @@ var (
 	userListHeader   = lipgloss.NewStyle().Bold(true).Underline(true)
 	onlineUserStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
 	offlineUserStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+
+	attachmentCardStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("13")).Italic(true)
 )
 
 // Model & State (updated)
@@ -34,6 +37,7 @@ const (
 	stateLoading viewState = iota
 	stateChatting
 	stateError
+	stateServerPicker
 )
 
 type model struct {
@@ -47,9 +51,42 @@ type model struct {
 	onlineUsers    map[string]bool
 	activeTabIndex int
 
-	chatViewport   viewport.Model
-	viewportReady  bool
-	lastChannelID  int64
+	chatViewport  viewport.Model
+	viewportReady bool
+	lastChannelID int64
+
+	// Modal overlay used by commands like /list and /who.
+	showModal  bool
+	modalTitle string
+	modalBody  string
+
+	// renderer draws message content; /raw swaps it for a plain passthrough.
+	renderer MessageRenderer
+	rawMode  bool
+
+	// attachmentCache holds fetched inline-image bytes by attachment ID;
+	// pendingAttachments tracks fetches already in flight so repeated
+	// renders (resize, tab switch, new messages) don't re-request them.
+	attachmentCache    map[int64]attachmentCacheEntry
+	pendingAttachments map[int64]bool
+
+	// peerKeyCache holds fetched DM peer public keys by user ID;
+	// pendingPeerKeys tracks fetches already in flight, mirroring
+	// attachmentCache/pendingAttachments above.
+	peerKeyCache    map[int64]peerKeyCacheEntry
+	pendingPeerKeys map[int64]bool
+
+	wsState WSState
+
+	keystore *pcrypto.Keystore
+
+	// Multi-server profile switching.
+	servers      map[string]*ServerSession
+	activeServer string
+	profileStore *ProfileStore
+	program      *tea.Program
+	showSwitcher bool
+	pickerIndex  int
 }
 
 // Messages for Tea Program (unchanged)
@@ -58,11 +95,19 @@ type historyLoadedMsg struct {
 	channelID int64
 	msgs      []Message
 }
-type wsMsg struct{ msg WebSocketMessage }
+
+// wsMsg carries its originating client so Update can route it to the right
+// ServerSession even when that server isn't the one currently shown.
+type wsMsg struct {
+	client *ApiClient
+	msg    WebSocketMessage
+}
 type errOccurredMsg struct{ err error }
 
-// UPDATED: Renamed for clarity and to match `main.go` call
-func InitialModel(client *ApiClient) model {
+// InitialModel builds the model. If client is non-nil, the TUI starts
+// already connected under profileName; otherwise it starts at the server
+// picker so the user can choose (or log into) a saved profile.
+func InitialModel(store *ProfileStore, profileName string, client *ApiClient) model {
 	ti := textinput.New()
 	ti.Placeholder = "Type a message and press Enter..."
 	ti.Focus()
@@ -70,11 +115,30 @@ func InitialModel(client *ApiClient) model {
 	ti.Width = 50
 
 	m := model{
-		client:      client,
-		state:       stateLoading,
-		textInput:   ti,
-		messages:    make(map[int64][]Message),
-		onlineUsers: make(map[string]bool),
+		state:              stateServerPicker,
+		textInput:          ti,
+		messages:           make(map[int64][]Message),
+		onlineUsers:        make(map[string]bool),
+		renderer:           newGlamourRenderer(),
+		servers:            make(map[string]*ServerSession),
+		profileStore:       store,
+		attachmentCache:    make(map[int64]attachmentCacheEntry),
+		pendingAttachments: make(map[int64]bool),
+		peerKeyCache:       make(map[int64]peerKeyCacheEntry),
+		pendingPeerKeys:    make(map[int64]bool),
+	}
+
+	if client != nil {
+		profile := Profile{Name: profileName}
+		if store != nil {
+			if p, ok := store.Find(profileName); ok {
+				profile = p
+			}
+		}
+		m.servers[profileName] = newServerSession(profileName, profile, client)
+		m.client = client
+		m.activeServer = profileName
+		m.state = stateLoading
 	}
 
 	return m
@@ -93,7 +157,7 @@ func fetchInitialDataCmd(client *ApiClient) tea.Cmd {
 
 func fetchHistoryCmd(client *ApiClient, channelID int64) tea.Cmd {
 	return func() tea.Msg {
-		msgs, err := client.GetMessages(channelID)
+		msgs, err := client.GetMessages(channelID, 0)
 		if err != nil {
 			return errOccurredMsg{err}
 		}
@@ -101,8 +165,41 @@ func fetchHistoryCmd(client *ApiClient, channelID int64) tea.Cmd {
 	}
 }
 
+// attachmentFetchCmds scans channelID's messages for image attachments that
+// haven't been fetched yet (and aren't already in flight) and returns one
+// fetchAttachmentCmd per attachment, marking each pending so the next
+// render of the same channel doesn't issue a duplicate request. It fetches
+// nothing at all when the terminal can't render inline images, since
+// renderInlineImage would just fall back to the text card anyway.
+func (m *model) attachmentFetchCmds(channelID int64) []tea.Cmd {
+	if detectGraphicsProtocol() == protocolNone {
+		return nil
+	}
+	var cmds []tea.Cmd
+	for _, msg := range m.messages[channelID] {
+		for _, att := range msg.Attachments {
+			if !strings.HasPrefix(att.MimeType, "image/") {
+				continue
+			}
+			if _, cached := m.attachmentCache[att.ID]; cached {
+				continue
+			}
+			if m.pendingAttachments[att.ID] {
+				continue
+			}
+			m.pendingAttachments[att.ID] = true
+			cmds = append(cmds, fetchAttachmentCmd(att))
+		}
+	}
+	return cmds
+}
+
 func (m model) Init() tea.Cmd {
-	return fetchInitialDataCmd(m.client)
+	cmds := []tea.Cmd{loadKeystoreCmd()}
+	if m.client != nil {
+		cmds = append(cmds, fetchInitialDataCmd(m.client))
+	}
+	return tea.Batch(cmds...)
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -129,13 +226,30 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				activeChannel := m.channels[m.activeTabIndex]
 				m.chatViewport.SetContent(m.renderMessagesContent(activeChannel.ID))
 				m.chatViewport.GotoBottom()
+				cmds = append(cmds, m.attachmentFetchCmds(activeChannel.ID)...)
+				cmds = append(cmds, m.peerKeyFetchCmds(activeChannel.ID)...)
 			}
 		}
 
 	case tea.KeyMsg:
+		if m.showSwitcher || m.state == stateServerPicker {
+			return m.updatePicker(msg)
+		}
 		switch msg.Type {
-		case tea.KeyCtrlC, tea.KeyEsc:
+		case tea.KeyCtrlC:
 			return m, tea.Quit
+		case tea.KeyEsc:
+			if m.showModal {
+				m.showModal = false
+				return m, nil
+			}
+			return m, tea.Quit
+		case tea.KeyCtrlS:
+			if m.state == stateChatting {
+				m.showSwitcher = true
+				m.pickerIndex = 0
+			}
+			return m, nil
 		case tea.KeyTab:
 			if len(m.channels) > 0 {
 				m.activeTabIndex = (m.activeTabIndex + 1) % len(m.channels)
@@ -144,9 +258,11 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				} else {
 					m.chatViewport.SetContent(m.renderMessagesContent(m.channels[m.activeTabIndex].ID))
 					m.chatViewport.GotoBottom()
+					cmds = append(cmds, m.attachmentFetchCmds(m.channels[m.activeTabIndex].ID)...)
 				}
+				cmds = append(cmds, m.peerKeyFetchCmds(m.channels[m.activeTabIndex].ID)...)
 			}
-			return m, cmd
+			return m, tea.Batch(append(cmds, cmd)...)
 		case tea.KeyShiftTab:
 			if len(m.channels) > 0 {
 				m.activeTabIndex--
@@ -158,16 +274,40 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				} else {
 					m.chatViewport.SetContent(m.renderMessagesContent(m.channels[m.activeTabIndex].ID))
 					m.chatViewport.GotoBottom()
+					cmds = append(cmds, m.attachmentFetchCmds(m.channels[m.activeTabIndex].ID)...)
 				}
+				cmds = append(cmds, m.peerKeyFetchCmds(m.channels[m.activeTabIndex].ID)...)
 			}
-			return m, cmd
+			return m, tea.Batch(append(cmds, cmd)...)
 		case tea.KeyEnter:
 			content := strings.TrimSpace(m.textInput.Value())
 			if content != "" && len(m.channels) > 0 {
-				activeChannel := m.channels[m.activeTabIndex]
-				err := m.client.SendMessage(activeChannel.ID, m.client.User.ID, content)
-				if err != nil {
-					m.err = fmt.Errorf("send failed: %w", err)
+				// Checked before dispatchCommand: stagedFilePath only matches
+				// a "/"- or "~"-prefixed line that also stat()s to a real
+				// file, so an absolute-path paste/drop (which also starts
+				// with "/") is staged as an upload instead of being swallowed
+				// by dispatchCommand as an unknown slash command. Genuine
+				// slash commands don't exist on disk and fall through.
+				if path, ok := stagedFilePath(content); ok {
+					cmd = runUpload(&m, []string{path})
+				} else if dispatchCmd, ok := dispatchCommand(&m, content); ok {
+					cmd = dispatchCmd
+				} else {
+					activeChannel := m.channels[m.activeTabIndex]
+					toSend := content
+					if activeChannel.Encrypted {
+						enc, encErr := m.encryptForChannel(activeChannel.ID, content)
+						if encErr != nil {
+							m.err = fmt.Errorf("encrypt failed: %w", encErr)
+							m.textInput.Reset()
+							m.chatViewport.GotoBottom()
+							return m, tea.Batch(cmds...)
+						}
+						toSend = enc
+					}
+					if err := m.client.SendMessage(activeChannel.ID, toSend, "", nil); err != nil {
+						m.err = fmt.Errorf("send failed: %w", err)
+					}
 				}
 				m.textInput.Reset()
 			}
@@ -199,13 +339,17 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case initialDataLoadedMsg:
 		m.state = stateChatting
 		for _, cat := range msg.cats {
-			m.channels = append(m.channels, cat.Channels...)
+			for _, ch := range cat.Channels {
+				ch.Encrypted = cat.Encrypted
+				m.channels = append(m.channels, ch)
+			}
 		}
 		sort.SliceStable(m.channels, func(i, j int) bool {
 			return m.channels[i].Position < m.channels[j].Position
 		})
 		if len(m.channels) > 0 {
 			cmds = append(cmds, fetchHistoryCmd(m.client, m.channels[0].ID))
+			cmds = append(cmds, m.peerKeyFetchCmds(m.channels[0].ID)...)
 			m.lastChannelID = m.channels[0].ID
 		} else {
 			m.err = fmt.Errorf("no channels found on server")
@@ -219,19 +363,29 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.chatViewport.SetContent(m.renderMessagesContent(msg.channelID))
 			m.chatViewport.GotoBottom()
 		}
+		cmds = append(cmds, m.attachmentFetchCmds(msg.channelID)...)
+		cmds = append(cmds, m.peerKeyFetchCmds(msg.channelID)...)
 
 	case wsMsg:
+		serverID, known := m.serverIDForClient(msg.client)
+		active := known && serverID == m.activeServer
 		switch msg.msg.Event {
 		case "new_message":
 			var newMsg Message
 			if err := json.Unmarshal(msg.msg.Payload, &newMsg); err == nil {
-				if _, ok := m.messages[newMsg.ChannelID]; !ok {
-					m.messages[newMsg.ChannelID] = make([]Message, 0)
-				}
-				m.messages[newMsg.ChannelID] = append(m.messages[newMsg.ChannelID], newMsg)
-				if m.viewportReady && len(m.channels) > 0 && m.channels[m.activeTabIndex].ID == newMsg.ChannelID {
-					m.chatViewport.SetContent(m.renderMessagesContent(newMsg.ChannelID))
-					m.chatViewport.GotoBottom()
+				if active || !known {
+					if _, ok := m.messages[newMsg.ChannelID]; !ok {
+						m.messages[newMsg.ChannelID] = make([]Message, 0)
+					}
+					m.messages[newMsg.ChannelID] = append(m.messages[newMsg.ChannelID], newMsg)
+					if m.viewportReady && len(m.channels) > 0 && m.channels[m.activeTabIndex].ID == newMsg.ChannelID {
+						m.chatViewport.SetContent(m.renderMessagesContent(newMsg.ChannelID))
+						m.chatViewport.GotoBottom()
+						cmds = append(cmds, m.attachmentFetchCmds(newMsg.ChannelID)...)
+						cmds = append(cmds, m.peerKeyFetchCmds(newMsg.ChannelID)...)
+					}
+				} else if s := m.servers[serverID]; s != nil {
+					s.Messages[newMsg.ChannelID] = append(s.Messages[newMsg.ChannelID], newMsg)
 				}
 			}
 		case "presence_update":
@@ -241,8 +395,85 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				for _, u := range users {
 					newOnlineUsers[u.Username] = true
 				}
-				m.onlineUsers = newOnlineUsers
+				if active || !known {
+					m.onlineUsers = newOnlineUsers
+				} else if s := m.servers[serverID]; s != nil {
+					s.OnlineUsers = newOnlineUsers
+				}
+			}
+		}
+
+	case wsStateMsg:
+		if serverID, known := m.serverIDForClient(msg.client); known && serverID != m.activeServer {
+			if s := m.servers[serverID]; s != nil {
+				s.WSState = msg.state
+			}
+		} else {
+			m.wsState = msg.state
+		}
+
+	case ProgramReadyMsg:
+		m.program = msg.Program
+
+	case keystoreReadyMsg:
+		m.keystore = msg.ks
+		if m.client != nil {
+			cmds = append(cmds, publishKeyCmd(m.client, msg.ks))
+		}
+
+	case cryptoErrorMsg:
+		m.pushSystemMessage(fmt.Sprintf("crypto: %v", msg.err))
+
+	case commandErrorMsg:
+		m.pushSystemMessage(fmt.Sprintf("%v", msg.err))
+
+	case verifyResultMsg:
+		m.pushSystemMessage(msg.text)
+
+	case nickChangedMsg:
+		m.pushSystemMessage(fmt.Sprintf("nick changed to %s", msg.name))
+
+	case listLoadedMsg:
+		var sb strings.Builder
+		for _, cat := range msg.cats {
+			sb.WriteString(cat.Name + "\n")
+			for _, ch := range cat.Channels {
+				sb.WriteString("  #" + ch.Name + "\n")
+			}
+		}
+		m.openModal("Channels", strings.TrimRight(sb.String(), "\n"))
+
+	case rosterLoadedMsg:
+		var sb strings.Builder
+		for _, u := range msg.users {
+			status := "offline"
+			if m.onlineUsers[u.Username] {
+				status = "online"
 			}
+			sb.WriteString(fmt.Sprintf("%-20s %-10s %s\n", u.Username, u.Role, status))
+		}
+		m.openModal("Roster", strings.TrimRight(sb.String(), "\n"))
+
+	case attachmentLoadedMsg:
+		delete(m.pendingAttachments, msg.id)
+		m.attachmentCache[msg.id] = attachmentCacheEntry{data: msg.data, err: msg.err}
+		if m.viewportReady && len(m.channels) > 0 {
+			activeChannel := m.channels[m.activeTabIndex]
+			m.chatViewport.SetContent(m.renderMessagesContent(activeChannel.ID))
+		}
+
+	case peerKeyLoadedMsg:
+		delete(m.pendingPeerKeys, msg.peerID)
+		m.peerKeyCache[msg.peerID] = peerKeyCacheEntry{pub: msg.pub, err: msg.err}
+		if msg.pinErr != nil {
+			m.pushSystemMessage(fmt.Sprintf("warning: failed to persist pinned key for user %d: %v", msg.peerID, msg.pinErr))
+		}
+		if msg.changed {
+			m.pushSystemMessage(fmt.Sprintf("WARNING: public key for user %d changed since last contact", msg.peerID))
+		}
+		if m.viewportReady && len(m.channels) > 0 {
+			activeChannel := m.channels[m.activeTabIndex]
+			m.chatViewport.SetContent(m.renderMessagesContent(activeChannel.ID))
 		}
 
 	case errOccurredMsg:
@@ -279,14 +510,27 @@ func (m model) View() string {
 	}
 
 	switch m.state {
+	case stateServerPicker:
+		return m.renderPicker("Choose a server")
 	case stateLoading:
 		return "Connecting and loading channels..."
 	case stateError:
 		return fmt.Sprintf("An error occurred: %v\n\nPress Esc or Ctrl+C to exit.", m.err)
 	default:
 		var chatContent strings.Builder
-		header := headerStyle.Width(m.width).Render(fmt.Sprintf("Logged in as: %s", m.client.User.Username))
+		header := headerStyle.Width(m.width).Render(fmt.Sprintf("Logged in as: %s [%s] (Ctrl+S: switch server)", m.client.CurrentUser().Username, m.wsStateLabel()))
 		chatContent.WriteString(header + "\n")
+
+		if m.showSwitcher {
+			chatContent.WriteString(m.renderPicker("Switch server"))
+			return chatContent.String()
+		}
+
+		if m.showModal {
+			chatContent.WriteString(m.renderModal())
+			return chatContent.String()
+		}
+
 		tabs := m.renderTabs()
 		chatContent.WriteString(tabs + "\n")
 		chatPane := m.renderMessagesViewport()
@@ -298,6 +542,17 @@ func (m model) View() string {
 	}
 }
 
+func (m model) wsStateLabel() string {
+	switch m.wsState {
+	case WSConnected:
+		return "connected"
+	case WSConnecting:
+		return "connecting..."
+	default:
+		return "disconnected"
+	}
+}
+
 func (m model) renderTabs() string {
 	var renderedTabs []string
 	for i, ch := range m.channels {
@@ -313,7 +568,7 @@ func (m model) renderTabs() string {
 // NEW: Use viewport for messages
 func (m model) renderMessagesViewport() string {
 	if !m.viewportReady {
-		return lipgloss.NewStyle().Width(m.width-25).Height(m.height-5).Render("Loading...")
+		return lipgloss.NewStyle().Width(m.width - 25).Height(m.height - 5).Render("Loading...")
 	}
 	return m.chatViewport.View()
 }
@@ -325,20 +580,64 @@ func (m model) renderMessagesContent(channelID int64) string {
 		chatWidth = 1
 	}
 	var sb strings.Builder
+	var channelEncrypted bool
+	for _, ch := range m.channels {
+		if ch.ID == channelID {
+			channelEncrypted = ch.Encrypted
+			break
+		}
+	}
 	msgs := m.messages[channelID]
 	for _, msg := range msgs {
 		timeStr := msg.CreatedAt.Format("15:04")
-		prefix := fmt.Sprintf("[%s] %s:", timeStr, msg.Username)
+		var line string
 		style := otherMsgStyle
-		if msg.Username == m.client.User.Username {
-			style = myMsgStyle
+		switch msg.Type {
+		case "system":
+			style = systemStyle
+			line = msg.Content
+		case "action":
+			style = systemStyle
+			line = fmt.Sprintf("[%s] * %s %s", timeStr, msg.Username, msg.Content)
+		default:
+			if msg.Username == m.client.CurrentUser().Username {
+				style = myMsgStyle
+			}
+			lock := ""
+			renderMsg := msg
+			if plain, isEnc := m.decryptContent(msg, channelEncrypted); isEnc {
+				lock = "\U0001F512 "
+				renderMsg.Content = plain
+			}
+			prefix := style.Render(fmt.Sprintf("[%s] %s%s:", timeStr, lock, msg.Username))
+			var body strings.Builder
+			if renderMsg.Content != "" {
+				body.WriteString(m.renderer.Render(renderMsg, chatWidth))
+			}
+			for _, att := range msg.Attachments {
+				if body.Len() > 0 {
+					body.WriteString("\n")
+				}
+				body.WriteString(renderInlineImage(att, m.attachmentCache))
+			}
+			sb.WriteString(prefix + " " + body.String() + "\n")
+			continue
 		}
-		line := fmt.Sprintf("%s %s", prefix, msg.Content)
 		sb.WriteString(style.Render(line) + "\n")
 	}
 	return sb.String()
 }
 
+// renderModal draws the /list and /who overlays over the full chat area.
+func (m model) renderModal() string {
+	height := m.height - 3
+	if height < 1 {
+		height = 1
+	}
+	body := m.modalTitle + "\n\n" + m.modalBody + "\n\n(Press Esc to close)"
+	return userListStyle.Width(m.width - 4).Height(height).Render(body)
+}
+
 func (m model) renderUserList() string {
 	listWidth := 20
 	listHeight := m.height - 5
@@ -359,4 +658,4 @@ func (m model) renderUserList() string {
 	}
 
 	return userListStyle.Width(listWidth).Height(listHeight).Render(sb.String())
-}
\ No newline at end of file
+}